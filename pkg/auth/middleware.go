@@ -0,0 +1,193 @@
+// Package auth provides a shared Gin middleware for validating the RS256
+// JWTs issued by the auth service, so every downstream service enforces
+// roles the same way instead of re-implementing JWKS fetching and token
+// parsing on its own.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// jwksRefreshInterval bounds how long a fetched JWKS is trusted before the
+// middleware re-fetches it from the auth service, so a newly rotated key
+// becomes visible without restarting dependent services.
+const jwksRefreshInterval = 5 * time.Minute
+
+// Middleware validates bearer tokens against the auth service's JWKS.
+type Middleware struct {
+	jwksURL string
+	client  *http.Client
+
+	mu        sync.RWMutex
+	keySet    jwk.Set
+	fetchedAt time.Time
+
+	revocation *RevocationCache
+}
+
+// New builds a Middleware that fetches its JWKS from jwksURL (the auth
+// service's `GET /.jwk` endpoint).
+func New(jwksURL string) *Middleware {
+	return &Middleware{
+		jwksURL: jwksURL,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// UseRevocationCache wires rc into the middleware so RequireRole rejects
+// bearer tokens whose jti has been revoked (e.g. via logout) before their
+// natural expiry.
+func (m *Middleware) UseRevocationCache(rc *RevocationCache) {
+	m.revocation = rc
+}
+
+// RequireRole returns a Gin middleware that validates the bearer token and,
+// if roles are given, rejects requests whose token doesn't carry at least
+// one of them. On success it sets "user_id" and "roles" in the Gin context.
+func (m *Middleware) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, err := bearerToken(c.GetHeader("Authorization"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or malformed bearer token"})
+			return
+		}
+
+		claims, err := m.verify(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		jti, _ := claims["jti"].(string)
+		if m.revocation != nil && m.revocation.IsRevoked(jti) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			return
+		}
+
+		userRoles := stringSlice(claims["roles"])
+		if len(roles) > 0 && !hasAnyRole(userRoles, roles) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+			return
+		}
+
+		c.Set("user_id", claims["sub"])
+		c.Set("roles", userRoles)
+		c.Next()
+	}
+}
+
+func bearerToken(header string) (string, error) {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || header[:len(prefix)] != prefix {
+		return "", errors.New("missing bearer prefix")
+	}
+	return header[len(prefix):], nil
+}
+
+func (m *Middleware) verify(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		keySet, err := m.jwks()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keySet.LookupKeyID(kid)
+		if !ok {
+			return nil, errors.New("unknown kid")
+		}
+		var raw interface{}
+		if err := key.Raw(&raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("token is invalid")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("unexpected claims type")
+	}
+	return claims, nil
+}
+
+// jwks returns the cached JWKS, refreshing it from the auth service if it's
+// older than jwksRefreshInterval.
+func (m *Middleware) jwks() (jwk.Set, error) {
+	m.mu.RLock()
+	stale := m.keySet == nil || time.Since(m.fetchedAt) > jwksRefreshInterval
+	keySet := m.keySet
+	m.mu.RUnlock()
+
+	if !stale {
+		return keySet, nil
+	}
+
+	resp, err := m.client.Get(m.jwksURL)
+	if err != nil {
+		if keySet != nil {
+			return keySet, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		if keySet != nil {
+			return keySet, nil
+		}
+		return nil, err
+	}
+
+	fetched, err := jwk.Parse(raw)
+	if err != nil {
+		if keySet != nil {
+			return keySet, nil
+		}
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.keySet = fetched
+	m.fetchedAt = time.Now()
+	m.mu.Unlock()
+
+	return fetched, nil
+}
+
+func stringSlice(v interface{}) []string {
+	raw, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func hasAnyRole(have, want []string) bool {
+	set := make(map[string]struct{}, len(have))
+	for _, r := range have {
+		set[r] = struct{}{}
+	}
+	for _, r := range want {
+		if _, ok := set[r]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// RevokedSubject is the NATS subject the auth service publishes on when an
+// access token's jti is revoked before its natural expiry (e.g. on logout),
+// so every service sharing this package can reject it without calling back
+// into the auth service on every request.
+const RevokedSubject = "auth.revoked"
+
+type revocationEvent struct {
+	JTI string `json:"jti"`
+	Exp int64  `json:"exp"`
+}
+
+// RevocationCache tracks jtis revoked before their natural expiry, pruning
+// entries once that expiry has passed so the set doesn't grow unbounded.
+type RevocationCache struct {
+	mu      sync.RWMutex
+	expires map[string]time.Time
+}
+
+// NewRevocationCache returns an empty RevocationCache.
+func NewRevocationCache() *RevocationCache {
+	return &RevocationCache{expires: make(map[string]time.Time)}
+}
+
+// Revoke marks jti as revoked until its natural expiry exp (a unix
+// timestamp).
+func (r *RevocationCache) Revoke(jti string, exp int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expires[jti] = time.Unix(exp, 0)
+}
+
+// IsRevoked reports whether jti was revoked and hasn't yet reached the
+// expiry it was revoked with.
+func (r *RevocationCache) IsRevoked(jti string) bool {
+	if jti == "" {
+		return false
+	}
+	r.mu.RLock()
+	expiresAt, ok := r.expires[jti]
+	r.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		r.mu.Lock()
+		delete(r.expires, jti)
+		r.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// Subscribe keeps the cache in sync with RevokedSubject events published by
+// the auth service, so a dependent service can reject a revoked access
+// token without calling back into the auth service on every request.
+func (r *RevocationCache) Subscribe(nc *nats.Conn) error {
+	_, err := nc.Subscribe(RevokedSubject, func(msg *nats.Msg) {
+		var event revocationEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("auth: invalid revocation event: %v", err)
+			return
+		}
+		r.Revoke(event.JTI, event.Exp)
+	})
+	return err
+}
+
+// SubscribeWithLogging is Subscribe plus the log-and-continue error handling
+// every dependent service wants around it, so post-services and
+// comments-service don't each need their own copy of that boilerplate.
+// serviceName is only used to label the failure log line.
+func (r *RevocationCache) SubscribeWithLogging(nc *nats.Conn, serviceName string) {
+	if err := r.Subscribe(nc); err != nil {
+		log.Printf("%s: failed to subscribe to %s: %v", serviceName, RevokedSubject, err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package outbox implements the transactional outbox pattern shared by
+// post-services and comments-service: a row is inserted into an outbox
+// table in the same transaction as the entity it describes, so a crash
+// between the DB write and the NATS publish can never lose the event — a
+// background Worker polls the table and publishes whatever it finds.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultPollInterval is how often a Worker looks for rows to publish when
+// PollInterval isn't set.
+const DefaultPollInterval = 2 * time.Second
+
+// DefaultBatchSize bounds how many rows a Worker publishes per poll when
+// BatchSize isn't set.
+const DefaultBatchSize = 50
+
+// EnsureSchema creates the outbox table named table if it doesn't already
+// exist.
+func EnsureSchema(ctx context.Context, pool *pgxpool.Pool, table string) error {
+	_, err := pool.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id UUID PRIMARY KEY,
+			subject TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`, table))
+	return err
+}
+
+// Insert marshals payload and writes it into table as a pending subject
+// event within tx, so it commits atomically with whatever row the caller is
+// already writing.
+func Insert(ctx context.Context, tx pgx.Tx, table, subject string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		fmt.Sprintf("INSERT INTO %s (id, subject, payload) VALUES ($1, $2, $3)", table),
+		uuid.New(), subject, data,
+	)
+	return err
+}
+
+// Worker polls Table for pending rows and publishes them to JS, deleting
+// each one once its publish is acked.
+type Worker struct {
+	Pool         *pgxpool.Pool
+	JS           nats.JetStreamContext
+	Table        string
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// Run publishes pending Table rows on a PollInterval ticker until ctx is
+// done.
+func (w *Worker) Run(ctx context.Context) {
+	interval := w.PollInterval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.publishPending(ctx); err != nil {
+				log.Printf("outbox: publish pass on %s failed: %v", w.Table, err)
+			}
+		}
+	}
+}
+
+type pendingRow struct {
+	id      uuid.UUID
+	subject string
+	payload []byte
+}
+
+func (w *Worker) publishPending(ctx context.Context) error {
+	batchSize := w.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	rows, err := w.Pool.Query(ctx,
+		fmt.Sprintf("SELECT id, subject, payload FROM %s ORDER BY created_at ASC LIMIT $1", w.Table),
+		batchSize)
+	if err != nil {
+		return err
+	}
+
+	var toPublish []pendingRow
+	for rows.Next() {
+		var r pendingRow
+		if err := rows.Scan(&r.id, &r.subject, &r.payload); err != nil {
+			rows.Close()
+			return err
+		}
+		toPublish = append(toPublish, r)
+	}
+	rows.Close()
+
+	for _, r := range toPublish {
+		future, err := w.JS.PublishAsync(r.subject, r.payload)
+		if err != nil {
+			log.Printf("outbox: failed to publish %s row %s: %v", w.Table, r.id, err)
+			continue
+		}
+
+		select {
+		case <-future.Ok():
+			if _, err := w.Pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", w.Table), r.id); err != nil {
+				log.Printf("outbox: failed to delete published %s row %s: %v", w.Table, r.id, err)
+			}
+		case err := <-future.Err():
+			log.Printf("outbox: %s row %s was not acked, will retry: %v", w.Table, r.id, err)
+		case <-time.After(5 * time.Second):
+			log.Printf("outbox: timed out waiting for ack on %s row %s, will retry", w.Table, r.id)
+		}
+	}
+
+	return nil
+}
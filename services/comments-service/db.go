@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ensureCommentsSchema creates the comments table and adds the `locked`
+// flag to posts that createCommentHandler enforces. post-services adds the
+// same column (IF NOT EXISTS) for its lock endpoint, so either service may
+// boot first.
+func (s *server) ensureCommentsSchema() error {
+	_, err := s.db.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS comments (
+			id UUID PRIMARY KEY,
+			post_id UUID NOT NULL,
+			user_id UUID NOT NULL,
+			parent_comment_id UUID,
+			content TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+
+		ALTER TABLE posts ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT false;
+	`)
+	return err
+}
+
+func (s *server) isPostLocked(postID uuid.UUID) (bool, error) {
+	var locked bool
+	err := s.db.QueryRow(context.Background(),
+		"SELECT locked FROM posts WHERE id = $1", postID,
+	).Scan(&locked)
+	return locked, err
+}
+
+// commentCursor is the decoded form of the opaque pagination cursor: the
+// created_at/id of the last comment the caller saw.
+type commentCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func encodeCommentCursor(c Comment, createdAt time.Time) string {
+	raw := fmt.Sprintf("%d:%s", createdAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCommentCursor(cursor string) (*commentCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var nanos int64
+	var idStr string
+	if _, err := fmt.Sscanf(string(raw), "%d:%s", &nanos, &idStr); err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+	return &commentCursor{CreatedAt: time.Unix(0, nanos), ID: id}, nil
+}
+
+const commentsPageSize = 20
+
+// listComments returns one page of comments for postID in the requested
+// order, using keyset pagination on (created_at, id) so pages stay stable
+// even as new comments are inserted.
+func (s *server) listComments(postID uuid.UUID, sortOrder, cursor string) ([]Comment, string, error) {
+	cur, err := decodeCommentCursor(cursor)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	cmp := ">"
+	orderBy := "ASC"
+	if sortOrder == "desc" {
+		cmp = "<"
+		orderBy = "DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, post_id, user_id, parent_comment_id, content, created_at
+		FROM comments
+		WHERE post_id = $1 %s
+		ORDER BY created_at %s, id %s
+		LIMIT $2
+	`, cursorClause(cur, cmp), orderBy, orderBy)
+
+	args := []interface{}{postID, commentsPageSize}
+	if cur != nil {
+		args = append(args, cur.CreatedAt, cur.ID)
+	}
+
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var comments []Comment
+	var lastCreatedAt time.Time
+	for rows.Next() {
+		var comment Comment
+		var createdAt time.Time
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID,
+			&comment.ParentCommentID, &comment.Content, &createdAt); err != nil {
+			return nil, "", err
+		}
+		comments = append(comments, comment)
+		lastCreatedAt = createdAt
+	}
+
+	var nextCursor string
+	if len(comments) == commentsPageSize {
+		nextCursor = encodeCommentCursor(comments[len(comments)-1], lastCreatedAt)
+	}
+
+	return comments, nextCursor, nil
+}
+
+func cursorClause(cur *commentCursor, cmp string) string {
+	if cur == nil {
+		return ""
+	}
+	return fmt.Sprintf("AND (created_at, id) %s ($3, $4)", cmp)
+}
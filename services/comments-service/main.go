@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hariomop12/nginx/pkg/auth"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/joho/godotenv"
+	"github.com/nats-io/nats.go"
+)
+
+// server holds everything the comments handlers need, so NewRouter can
+// build one per call instead of stashing dependencies in package globals —
+// two NewRouter calls in the same process get fully independent state.
+type server struct {
+	db         *pgxpool.Pool
+	nc         *nats.Conn
+	js         nats.JetStreamContext
+	revocation *auth.RevocationCache
+}
+
+type Comment struct {
+	ID              uuid.UUID  `json:"id"`
+	PostID          uuid.UUID  `json:"post_id"`
+	UserID          uuid.UUID  `json:"user_id"`
+	ParentCommentID *uuid.UUID `json:"parent_comment_id,omitempty"`
+	Content         string     `json:"content"`
+}
+
+type CreateCommentRequest struct {
+	Content         string     `json:"content" binding:"required"`
+	ParentCommentID *uuid.UUID `json:"parent_comment_id"`
+}
+
+type CommentEvent struct {
+	PostID    string `json:"post_id"`
+	CommentID string `json:"comment_id"`
+	Content   string `json:"content"`
+}
+
+// Deps holds everything NewRouter needs to wire up the service, kept
+// separate from the env-var lookups in main. The integration tests under
+// test/ don't build the router through Deps: they start the service with
+// `go run` and configure it through the same environment variables.
+type Deps struct {
+	DB      *pgxpool.Pool
+	NATS    *nats.Conn
+	JWKSURL string // the auth service's /.jwk, for verifying bearer tokens
+}
+
+// NewRouter builds the comments service's routes against deps: declaring
+// the JetStream stream, creating the comments/outbox tables, and starting
+// the background outbox worker as a side effect. Each call builds its own
+// server, so independent NewRouter calls (e.g. one per test) never share
+// state.
+func NewRouter(deps Deps) (*gin.Engine, error) {
+	s := &server{
+		db:         deps.DB,
+		nc:         deps.NATS,
+		revocation: auth.NewRevocationCache(),
+	}
+
+	if err := s.ensureCommentsSchema(); err != nil {
+		return nil, fmt.Errorf("creating comments table: %w", err)
+	}
+	if err := s.ensureCommentOutboxSchema(); err != nil {
+		return nil, fmt.Errorf("creating comment_outbox table: %w", err)
+	}
+
+	js, err := s.nc.JetStream()
+	if err != nil {
+		return nil, fmt.Errorf("getting JetStream context: %w", err)
+	}
+	s.js = js
+	if err := declareCommentsStream(s.js); err != nil {
+		return nil, fmt.Errorf("declaring %s stream: %w", commentsStreamName, err)
+	}
+
+	go s.runCommentOutboxWorker(context.Background())
+
+	s.revocation.SubscribeWithLogging(s.nc, "comments-service")
+
+	authMiddleware := auth.New(deps.JWKSURL)
+	authMiddleware.UseRevocationCache(s.revocation)
+
+	r := gin.Default()
+	r.POST("/posts/:id/comments", authMiddleware.RequireRole("user"), s.createCommentHandler)
+	r.GET("/posts/:id/comments", s.listCommentsHandler)
+	r.DELETE("/comments/:id", authMiddleware.RequireRole("admin"), s.deleteCommentHandler)
+
+	return r, nil
+}
+
+func main() {
+	// Load environment variables from .env file for local development
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+
+	// --- DB Connection ---
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL environment variable is not set")
+	}
+	dbPool, err := pgxpool.Connect(context.Background(), databaseURL)
+	if err != nil {
+		log.Fatalf("Unable to connect to database: %v\n", err)
+	}
+	defer dbPool.Close()
+	log.Println("Connected to database")
+
+	// --- NATS Connection ---
+	natsURL := os.Getenv("NATS_URL")
+	if natsURL == "" {
+		log.Fatal("NATS_URL environment variable is not set")
+	}
+	natsConn, err := nats.Connect(natsURL)
+	if err != nil {
+		log.Fatalf("Unable to connect to NATS: %v\n", err)
+	}
+	defer natsConn.Close()
+	log.Println("Connected to NATS")
+
+	jwksURL := os.Getenv("AUTH_SERVICE_JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://localhost:8080/.jwk"
+	}
+
+	r, err := NewRouter(Deps{DB: dbPool, NATS: natsConn, JWKSURL: jwksURL})
+	if err != nil {
+		log.Fatalf("Error building router: %v\n", err)
+	}
+
+	r.Run(":8084")
+}
+
+// createCommentHandler posts a new comment, refusing non-admins when the
+// parent post has been locked.
+func (s *server) createCommentHandler(c *gin.Context) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+
+	var req CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	locked, err := s.isPostLocked(postID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up post"})
+		return
+	}
+	if locked && !hasRole(c, "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This post is locked for new comments"})
+		return
+	}
+
+	userIDstr, _ := c.MustGet("user_id").(string)
+	userID, err := uuid.Parse(userIDstr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	comment := Comment{
+		ID:              uuid.New(),
+		PostID:          postID,
+		UserID:          userID,
+		ParentCommentID: req.ParentCommentID,
+		Content:         req.Content,
+	}
+
+	event := CommentEvent{
+		PostID:    comment.PostID.String(),
+		CommentID: comment.ID.String(),
+		Content:   comment.Content,
+	}
+
+	if err := s.insertCommentWithOutbox(comment, event); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// listCommentsHandler returns a page of comments for a post, newest or
+// oldest first, keyset-paginated by an opaque cursor (the created_at and id
+// of the last comment seen by the caller).
+func (s *server) listCommentsHandler(c *gin.Context) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+
+	sortOrder := c.DefaultQuery("sort", "asc")
+	if sortOrder != "asc" && sortOrder != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be 'asc' or 'desc'"})
+		return
+	}
+
+	comments, nextCursor, err := s.listComments(postID, sortOrder, c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"comments": comments, "next_cursor": nextCursor})
+}
+
+// deleteCommentHandler removes a comment outright. Mounted behind
+// RequireRole("admin"), mirroring the posts service's moderation delete.
+// The delete and its comment.deleted outbox event commit together so the
+// search service's index can't drift from the moderation action.
+func (s *server) deleteCommentHandler(c *gin.Context) {
+	commentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	deleted, err := s.deleteCommentWithOutbox(commentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": commentID})
+}
+
+func hasRole(c *gin.Context, role string) bool {
+	roles, _ := c.Get("roles")
+	list, ok := roles.([]string)
+	if !ok {
+		return false
+	}
+	for _, r := range list {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
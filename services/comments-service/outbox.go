@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/hariomop12/nginx/pkg/outbox"
+	"github.com/nats-io/nats.go"
+)
+
+// commentsStreamName is the JetStream stream that durably holds every
+// subject under comment.>, following the same outbox pattern the posts
+// service uses for post.>.
+const commentsStreamName = "COMMENTS"
+
+// commentOutboxTable holds pending comment events, following the same
+// transactional outbox pattern as postOutboxTable in post-services.
+const commentOutboxTable = "comment_outbox"
+
+func (s *server) ensureCommentOutboxSchema() error {
+	return outbox.EnsureSchema(context.Background(), s.db, commentOutboxTable)
+}
+
+func declareCommentsStream(js nats.JetStreamContext) error {
+	_, err := js.StreamInfo(commentsStreamName)
+	if err == nil {
+		return nil
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     commentsStreamName,
+		Subjects: []string{"comment.>"},
+	})
+	return err
+}
+
+// CommentDeletedEvent carries enough of a deleted comment to let the search
+// service remove it from its index.
+type CommentDeletedEvent struct {
+	CommentID string `json:"comment_id"`
+}
+
+// deleteCommentWithOutbox deletes the comment and writes a comment.deleted
+// outbox event in the same transaction, so the search index can never
+// drift from an admin moderation delete. It reports whether a comment was
+// actually deleted, so the handler can distinguish a missing comment from a
+// failure.
+func (s *server) deleteCommentWithOutbox(commentID uuid.UUID) (bool, error) {
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, "DELETE FROM comments WHERE id = $1", commentID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := outbox.Insert(ctx, tx, commentOutboxTable, "comment.deleted", CommentDeletedEvent{CommentID: commentID.String()}); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// insertCommentWithOutbox writes the comment and its outbox event in a
+// single transaction so the two can never diverge.
+func (s *server) insertCommentWithOutbox(comment Comment, event CommentEvent) error {
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		"INSERT INTO comments (id, post_id, user_id, parent_comment_id, content) VALUES ($1, $2, $3, $4, $5)",
+		comment.ID, comment.PostID, comment.UserID, comment.ParentCommentID, comment.Content,
+	); err != nil {
+		return err
+	}
+
+	if err := outbox.Insert(ctx, tx, commentOutboxTable, "comment.created", event); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// runCommentOutboxWorker publishes pending comment_outbox rows to JetStream
+// until ctx is done, using the transactional outbox pattern shared with
+// post-services.
+func (s *server) runCommentOutboxWorker(ctx context.Context) {
+	(&outbox.Worker{Pool: s.db, JS: s.js, Table: commentOutboxTable}).Run(ctx)
+}
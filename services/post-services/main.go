@@ -2,28 +2,36 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/hariomop12/nginx/pkg/auth"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/nats-io/nats.go"
 )
 
-var (
-	dbPool *pgxpool.Pool
-	nc     *nats.Conn
-)
+// server holds everything the posts handlers need, so NewRouter can build
+// one per call instead of stashing dependencies in package globals — two
+// NewRouter calls in the same process get fully independent state.
+type server struct {
+	db         *pgxpool.Pool
+	nc         *nats.Conn
+	js         nats.JetStreamContext
+	revocation *auth.RevocationCache
+}
 
 type Post struct {
-	ID      uuid.UUID `json:"id"`
-	UserID  uuid.UUID `json:"user_id"`
-	Title   string    `json:"title"`
-	Content string    `json:"content"`
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type CreatePostRequest struct {
@@ -31,10 +39,73 @@ type CreatePostRequest struct {
 	Content string `json:"content" binding:"required"`
 }
 
+// LockPostRequest toggles moderation locking of a post; comments-service's
+// createCommentHandler checks this flag to refuse new comments from
+// non-admins once a post is locked.
+type LockPostRequest struct {
+	Locked bool `json:"locked"`
+}
+
+// PostEvent carries enough of the post to let the search service rank,
+// filter, and snippet it without a callback to this service.
 type PostEvent struct {
-	ID      string `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Deps holds everything NewRouter needs to wire up the service, kept
+// separate from the env-var lookups in main. The integration tests under
+// test/ don't build the router through Deps: they start the service with
+// `go run` and configure it through the same environment variables.
+type Deps struct {
+	DB      *pgxpool.Pool
+	NATS    *nats.Conn
+	JWKSURL string // the auth service's /.jwk, for verifying bearer tokens
+}
+
+// NewRouter builds the posts service's routes against deps: declaring the
+// JetStream stream, creating the outbox table, and starting the
+// background outbox worker as a side effect. Each call builds its own
+// server, so independent NewRouter calls (e.g. one per test) never share
+// state.
+func NewRouter(deps Deps) (*gin.Engine, error) {
+	js, err := deps.NATS.JetStream()
+	if err != nil {
+		return nil, fmt.Errorf("getting JetStream context: %w", err)
+	}
+
+	s := &server{
+		db:         deps.DB,
+		nc:         deps.NATS,
+		js:         js,
+		revocation: auth.NewRevocationCache(),
+	}
+
+	if err := declarePostsStream(s.js); err != nil {
+		return nil, fmt.Errorf("declaring %s stream: %w", postsStreamName, err)
+	}
+	if err := s.ensurePostsSchema(); err != nil {
+		return nil, fmt.Errorf("creating post_outbox table: %w", err)
+	}
+
+	go s.runOutboxWorker(context.Background())
+
+	s.revocation.SubscribeWithLogging(s.nc, "post-services")
+
+	authMiddleware := auth.New(deps.JWKSURL)
+	authMiddleware.UseRevocationCache(s.revocation)
+
+	r := gin.Default()
+	r.POST("/posts", authMiddleware.RequireRole("user"), s.createPostHandler)
+	r.DELETE("/posts/:id", authMiddleware.RequireRole("admin"), s.deletePostHandler)
+	r.POST("/posts/:id/lock", authMiddleware.RequireRole("admin"), s.lockPostHandler)
+	// r.GET("/posts/:id", getPostByIdHandler)  // TODO: Implement this handler
+	// r.GET("/posts", getAllPostsHandler)     // TODO: Implement this handler
+
+	return r, nil
 }
 
 func main() {
@@ -43,13 +114,12 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	var err error
 	// --- DB Connection ---
-	DATABASE_URL := os.Getenv("DATABASE_URL")
-	if DATABASE_URL == "" {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
 		log.Fatal("DATABASE_URL environment variable is not set")
 	}
-	dbPool, err = pgxpool.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	dbPool, err := pgxpool.Connect(context.Background(), databaseURL)
 	if err != nil {
 		log.Fatalf("Unable to connect to database: %v\n", err)
 	}
@@ -61,72 +131,115 @@ func main() {
 	if natsURL == "" {
 		log.Fatal("NATS_URL environment variable is not set")
 	}
-	nc, err = nats.Connect(natsURL)
+	natsConn, err := nats.Connect(natsURL)
 	if err != nil {
 		log.Fatalf("Unable to connect to NATS: %v\n", err)
 	}
-	defer nc.Close()
+	defer natsConn.Close()
 	log.Println("Connected to NATS")
 
-	// --- Gin Router ---
-	r := gin.Default()
-	r.POST("/posts", createPostHandler)
-	// r.GET("/posts/:id", getPostByIdHandler)  // TODO: Implement this handler
-	// r.GET("/posts", getAllPostsHandler)     // TODO: Implement this handler
-	r.Run(":8082") // Run on port 8082
+	jwksURL := os.Getenv("AUTH_SERVICE_JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://localhost:8080/.jwk"
+	}
 
+	r, err := NewRouter(Deps{DB: dbPool, NATS: natsConn, JWKSURL: jwksURL})
+	if err != nil {
+		log.Fatalf("Error building router: %v\n", err)
+	}
+
+	r.Run(":8082") // Run on port 8082
 }
 
-func createPostHandler(c *gin.Context) {
+func (s *server) createPostHandler(c *gin.Context) {
 	var req CreatePostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(
 			http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// KrakenD will pass the JWT claims as headers, prefixed with 'X-Krakend-'
 
-	userIDstr := c.GetHeader("X-Krakend-Sub")
+	userIDstr, _ := c.MustGet("user_id").(string)
 	userID, err := uuid.Parse(userIDstr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 	newPost := Post{
-		ID:      uuid.New(),
-		UserID:  userID,
-		Title:   req.Title,
-		Content: req.Content,
+		ID:        uuid.New(),
+		UserID:    userID,
+		Title:     req.Title,
+		Content:   req.Content,
+		CreatedAt: time.Now(),
 	}
 
-	_, err = dbPool.Exec(context.Background(),
-		"INSERT INTO posts (id, user_id, title, content) VALUES ($1, $2, $3, $4)",
-		newPost.ID, newPost.UserID,
-		newPost.Title, newPost.Content)
-	if err != nil {
+	event := PostEvent{
+		ID:        newPost.ID.String(),
+		UserID:    newPost.UserID.String(),
+		Title:     newPost.Title,
+		Content:   newPost.Content,
+		CreatedAt: newPost.CreatedAt,
+	}
+
+	if err := s.insertPostWithOutbox(newPost, event); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
 		return
 	}
 	log.Printf("Post created: %v", newPost)
 
-	// Publish event to NATS
-	event := PostEvent{
-		ID:      newPost.ID.String(),
-		Title:   newPost.Title,
-		Content: newPost.Content,
+	c.JSON(http.StatusCreated, newPost)
+}
+
+// deletePostHandler removes a post outright. Mounted behind
+// RequireRole("admin") since regular users don't get moderation powers.
+// The delete and its post.deleted outbox event commit together so the
+// search service's index can't drift from the moderation action.
+func (s *server) deletePostHandler(c *gin.Context) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
 	}
 
-	eventBytes, err := json.Marshal(event)
+	deleted, err := s.deletePostWithOutbox(postID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to marshal event"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
 		return
 	}
 
-	// Publish to NATS
-	err = nc.Publish("post.updated", eventBytes)
+	c.JSON(http.StatusOK, gin.H{"id": postID})
+}
+
+// lockPostHandler sets or clears a post's locked flag. Mounted behind
+// RequireRole("admin"); this is the only way the flag enforced by
+// comments-service's createCommentHandler ever becomes reachable through
+// the API.
+func (s *server) lockPostHandler(c *gin.Context) {
+	postID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		log.Printf("Failed to publish event: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
 	}
-	log.Printf("Post created event published: %v", event)
-	c.JSON(http.StatusCreated, newPost)
+
+	var req LockPostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	found, err := s.setPostLocked(postID, req.Locked)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": postID, "locked": req.Locked})
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/hariomop12/nginx/pkg/outbox"
+	"github.com/nats-io/nats.go"
+)
+
+// postsStreamName is the JetStream stream that durably holds every subject
+// under post.>, so a subscriber that's offline when an event publishes can
+// still catch up instead of missing it.
+const postsStreamName = "POSTS"
+
+// postOutboxTable holds pending post events: a row is inserted in the same
+// transaction as the post itself, so a crash between the DB write and the
+// NATS publish can never lose the event — the shared outbox.Worker will
+// find it and retry.
+const postOutboxTable = "post_outbox"
+
+// ensurePostsSchema creates the post_outbox table used to publish post
+// events durably.
+func (s *server) ensurePostsSchema() error {
+	ctx := context.Background()
+	if err := outbox.EnsureSchema(ctx, s.db, postOutboxTable); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(ctx,
+		"ALTER TABLE posts ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now()"); err != nil {
+		return err
+	}
+	// locked is also added (IF NOT EXISTS) by comments-service's own
+	// migration, since createCommentHandler enforces it; declared here too
+	// so lockPostHandler works even if posts-services boots first.
+	_, err := s.db.Exec(ctx,
+		"ALTER TABLE posts ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT false")
+	return err
+}
+
+// declarePostsStream makes sure the POSTS JetStream stream exists, creating
+// it on first boot.
+func declarePostsStream(js nats.JetStreamContext) error {
+	_, err := js.StreamInfo(postsStreamName)
+	if err == nil {
+		return nil
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     postsStreamName,
+		Subjects: []string{"post.>"},
+	})
+	return err
+}
+
+// PostDeletedEvent carries enough of a deleted post to let the search
+// service remove it from its index.
+type PostDeletedEvent struct {
+	ID string `json:"id"`
+}
+
+// deletePostWithOutbox deletes the post and writes a post.deleted outbox
+// event in the same transaction, so the search index can never drift from
+// an admin moderation delete. It reports whether a post was actually
+// deleted, so the handler can distinguish a missing post from a failure.
+func (s *server) deletePostWithOutbox(postID uuid.UUID) (bool, error) {
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, "DELETE FROM posts WHERE id = $1", postID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := outbox.Insert(ctx, tx, postOutboxTable, "post.deleted", PostDeletedEvent{ID: postID.String()}); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// insertPostWithOutbox writes the post and its outbox event in a single
+// transaction so the two can never diverge.
+func (s *server) insertPostWithOutbox(post Post, event PostEvent) error {
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		"INSERT INTO posts (id, user_id, title, content, created_at) VALUES ($1, $2, $3, $4, $5)",
+		post.ID, post.UserID, post.Title, post.Content, post.CreatedAt,
+	); err != nil {
+		return err
+	}
+
+	if err := outbox.Insert(ctx, tx, postOutboxTable, "post.updated", event); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// runOutboxWorker publishes pending post_outbox rows to JetStream until ctx
+// is done, using the transactional outbox pattern shared with
+// comments-service.
+func (s *server) runOutboxWorker(ctx context.Context) {
+	(&outbox.Worker{Pool: s.db, JS: s.js, Table: postOutboxTable}).Run(ctx)
+}
+
+// setPostLocked flips posts.locked, which comments-service's
+// createCommentHandler enforces to refuse new comments from non-admins. It
+// reports whether a post was actually found, so the handler can
+// distinguish a missing post from a failure.
+func (s *server) setPostLocked(postID uuid.UUID, locked bool) (bool, error) {
+	tag, err := s.db.Exec(context.Background(),
+		"UPDATE posts SET locked = $1 WHERE id = $2", locked, postID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
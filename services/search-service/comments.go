@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/nats-io/nats.go"
+)
+
+// CommentEvent mirrors the payload the comments service publishes on
+// comment.created.
+type CommentEvent struct {
+	PostID    string `json:"post_id"`
+	CommentID string `json:"comment_id"`
+	Content   string `json:"content"`
+}
+
+func setupCommentsSchema(dbpool *pgxpool.Pool) {
+	_, err := dbpool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS comments_search_index (
+			comment_id UUID PRIMARY KEY,
+			post_id UUID NOT NULL,
+			content TEXT,
+			content_tsv TSVECTOR
+		);
+	`)
+	if err != nil {
+		log.Fatalf("Failed to create comments_search_index table: %v", err)
+	}
+
+	_, err = dbpool.Exec(context.Background(), `
+		CREATE INDEX IF NOT EXISTS comments_content_tsv_idx ON comments_search_index USING GIN(content_tsv);
+	`)
+	if err != nil {
+		log.Fatalf("Failed to create comments GIN index: %v", err)
+	}
+
+	_, err = dbpool.Exec(context.Background(), `
+		CREATE OR REPLACE FUNCTION update_comments_tsv() RETURNS trigger AS $$
+		BEGIN
+			NEW.content_tsv := to_tsvector('english', NEW.content);
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS comments_tsvector_update ON comments_search_index;
+		CREATE TRIGGER comments_tsvector_update BEFORE INSERT OR UPDATE
+		ON comments_search_index FOR EACH ROW EXECUTE PROCEDURE update_comments_tsv();
+	`)
+	if err != nil {
+		log.Fatalf("Failed to create comments tsvector trigger: %v", err)
+	}
+}
+
+// subscribeCommentsDurable indexes comment.created events the same way
+// subscribeDurable indexes post.updated ones.
+func subscribeCommentsDurable(js nats.JetStreamContext, dbpool *pgxpool.Pool) (*nats.Subscription, error) {
+	return js.Subscribe("comment.created", func(msg *nats.Msg) {
+		var event CommentEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("Invalid comment event format: %v", err)
+			deadLetterOrNak(msg)
+			return
+		}
+
+		upsertComment(dbpool, event)
+		if err := msg.Ack(); err != nil {
+			log.Printf("Failed to ack comment message: %v", err)
+		}
+	}, nats.Durable("comment-indexer"), nats.ManualAck(), nats.AckExplicit(), nats.MaxDeliver(maxDeliverAttempts))
+}
+
+// commentDeletedDLQSubject receives comment.deleted events that exhausted
+// their redelivery attempts.
+const commentDeletedDLQSubject = "comment.deleted.dlq"
+
+// CommentDeletedEvent mirrors the payload the comments service publishes on
+// comment.deleted.
+type CommentDeletedEvent struct {
+	CommentID string `json:"comment_id"`
+}
+
+// subscribeCommentDeletesDurable creates (or reattaches to) a durable push
+// consumer for comment.deleted and removes the row from
+// comments_search_index for each event, so an admin moderation delete
+// doesn't leave the comment searchable forever.
+func subscribeCommentDeletesDurable(js nats.JetStreamContext, dbpool *pgxpool.Pool) (*nats.Subscription, error) {
+	return js.Subscribe("comment.deleted", func(msg *nats.Msg) {
+		var event CommentDeletedEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("Invalid comment.deleted message format: %v", err)
+			deadLetterOrNakTo(msg, commentDeletedDLQSubject)
+			return
+		}
+
+		if _, err := dbpool.Exec(context.Background(),
+			"DELETE FROM comments_search_index WHERE comment_id = $1", event.CommentID); err != nil {
+			log.Printf("Failed to remove comment %s from search index: %v", event.CommentID, err)
+			msg.Nak()
+			return
+		}
+		if err := msg.Ack(); err != nil {
+			log.Printf("Failed to ack comment.deleted message: %v", err)
+		}
+	}, nats.Durable("comment-delete-indexer"), nats.ManualAck(), nats.AckExplicit(), nats.MaxDeliver(maxDeliverAttempts))
+}
+
+func upsertComment(dbpool *pgxpool.Pool, event CommentEvent) {
+	_, err := dbpool.Exec(context.Background(), `
+		INSERT INTO comments_search_index (comment_id, post_id, content)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (comment_id)
+		DO UPDATE SET content = EXCLUDED.content;
+	`, event.CommentID, event.PostID, event.Content)
+
+	if err != nil {
+		log.Printf("Failed to index comment %s: %v", event.CommentID, err)
+	} else {
+		log.Printf("Successfully indexed comment %s", event.CommentID)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/nats-io/nats.go"
+)
+
+// postsDLQSubject receives events that exhausted their redelivery attempts,
+// so they can be inspected and replayed manually instead of being dropped.
+const postsDLQSubject = "post.updated.dlq"
+
+// maxDeliverAttempts bounds how many times JetStream redelivers an event
+// before the consumer gives up; deadLetterOrNakTo then routes it to that
+// consumer's DLQ subject.
+const maxDeliverAttempts = 5
+
+// subscribeDurable creates (or reattaches to) a durable push consumer on
+// the POSTS stream and indexes every post.updated event it delivers,
+// acking explicitly so a crash mid-index redelivers instead of losing the
+// event.
+func subscribeDurable(js nats.JetStreamContext, dbpool *pgxpool.Pool) (*nats.Subscription, error) {
+	return js.Subscribe("post.updated", func(msg *nats.Msg) {
+		var post PostEvent
+		if err := json.Unmarshal(msg.Data, &post); err != nil {
+			log.Printf("Invalid message format: %v", err)
+			deadLetterOrNak(msg)
+			return
+		}
+
+		upsertPost(dbpool, post)
+		if err := msg.Ack(); err != nil {
+			log.Printf("Failed to ack message: %v", err)
+		}
+	}, nats.Durable("search-indexer"), nats.ManualAck(), nats.AckExplicit(), nats.MaxDeliver(maxDeliverAttempts))
+}
+
+// deadLetterOrNak routes a message to the DLQ once it has exhausted its
+// delivery attempts; otherwise it's nak'd so JetStream redelivers it.
+func deadLetterOrNak(msg *nats.Msg) {
+	deadLetterOrNakTo(msg, postsDLQSubject)
+}
+
+// deadLetterOrNakTo routes a message to dlqSubject once it has exhausted
+// its delivery attempts; otherwise it's nak'd so JetStream redelivers it.
+func deadLetterOrNakTo(msg *nats.Msg, dlqSubject string) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		msg.Nak()
+		return
+	}
+
+	if meta.NumDelivered >= maxDeliverAttempts {
+		if err := msg.NatsConn().Publish(dlqSubject, msg.Data); err != nil {
+			log.Printf("Failed to publish to DLQ %s: %v", dlqSubject, err)
+		}
+		msg.Term()
+		return
+	}
+
+	msg.Nak()
+}
+
+// postDeletedDLQSubject receives post.deleted events that exhausted their
+// redelivery attempts.
+const postDeletedDLQSubject = "post.deleted.dlq"
+
+// PostDeletedEvent mirrors the payload post-services publishes on
+// post.deleted.
+type PostDeletedEvent struct {
+	ID string `json:"id"`
+}
+
+// subscribeDeletesDurable creates (or reattaches to) a durable push
+// consumer for post.deleted and removes the row from posts_search_index for
+// each event, so an admin moderation delete doesn't leave the post
+// searchable forever.
+func subscribeDeletesDurable(js nats.JetStreamContext, dbpool *pgxpool.Pool) (*nats.Subscription, error) {
+	return js.Subscribe("post.deleted", func(msg *nats.Msg) {
+		var event PostDeletedEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("Invalid post.deleted message format: %v", err)
+			deadLetterOrNakTo(msg, postDeletedDLQSubject)
+			return
+		}
+
+		if _, err := dbpool.Exec(context.Background(),
+			"DELETE FROM posts_search_index WHERE post_id = $1", event.ID); err != nil {
+			log.Printf("Failed to remove post %s from search index: %v", event.ID, err)
+			msg.Nak()
+			return
+		}
+		if err := msg.Ack(); err != nil {
+			log.Printf("Failed to ack post.deleted message: %v", err)
+		}
+	}, nats.Durable("search-delete-indexer"), nats.ManualAck(), nats.AckExplicit(), nats.MaxDeliver(maxDeliverAttempts))
+}
+
+// reindexHandler rebuilds posts_search_index from scratch by replaying the
+// POSTS stream from sequence 0, rather than trusting whatever state the
+// durable consumer has accumulated.
+func reindexHandler(js nats.JetStreamContext, dbpool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count, err := replayPostsStream(js, dbpool)
+		if err != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("reindex failed: %v", err)})
+			return
+		}
+		c.JSON(200, gin.H{"reindexed": count})
+	}
+}
+
+// replayPostsStream rebuilds posts_search_index by replaying both
+// post.updated and post.deleted from sequence 0 in delivery order, so a
+// post that was indexed and later moderation-deleted doesn't come back:
+// reading post.updated alone would resurrect it, since JetStream still
+// retains that earlier event.
+func replayPostsStream(js nats.JetStreamContext, dbpool *pgxpool.Pool) (int, error) {
+	if _, err := dbpool.Exec(context.Background(), "TRUNCATE posts_search_index"); err != nil {
+		return 0, err
+	}
+
+	consumerName := fmt.Sprintf("reindex-%d", time.Now().UnixNano())
+	sub, err := js.PullSubscribe("post.>", consumerName, nats.DeliverAll(), nats.AckNone())
+	if err != nil {
+		return 0, err
+	}
+	defer sub.Unsubscribe()
+
+	count := 0
+	for {
+		msgs, err := sub.Fetch(100, nats.MaxWait(2*time.Second))
+		if err != nil {
+			if err == nats.ErrTimeout {
+				break
+			}
+			return count, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+
+		for _, msg := range msgs {
+			switch msg.Subject {
+			case "post.updated":
+				var post PostEvent
+				if err := json.Unmarshal(msg.Data, &post); err != nil {
+					log.Printf("Skipping unparseable post.updated message during reindex: %v", err)
+					continue
+				}
+				upsertPost(dbpool, post)
+				count++
+			case "post.deleted":
+				var event PostDeletedEvent
+				if err := json.Unmarshal(msg.Data, &event); err != nil {
+					log.Printf("Skipping unparseable post.deleted message during reindex: %v", err)
+					continue
+				}
+				if _, err := dbpool.Exec(context.Background(),
+					"DELETE FROM posts_search_index WHERE post_id = $1", event.ID); err != nil {
+					log.Printf("Failed to apply post.deleted during reindex: %v", err)
+				}
+			}
+		}
+	}
+
+	return count, nil
+}
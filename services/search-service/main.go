@@ -2,21 +2,73 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/hariomop12/nginx/pkg/auth"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/nats-io/nats.go"
 )
 
 type PostEvent struct {
-	ID      uuid.UUID `json:"id"`
-	Title   string    `json:"title"`
-	Content string    `json:"content"`
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Deps holds everything NewRouter needs to wire up the service, kept
+// separate from the env-var lookups in main. The integration tests under
+// test/ don't build the router through Deps: they start the service with
+// `go run` and configure it through the same environment variables.
+type Deps struct {
+	DB      *pgxpool.Pool
+	NATS    *nats.Conn
+	JWKSURL string // the auth service's /.jwk, for gating /admin/reindex
+}
+
+// NewRouter builds the search service's routes against deps: running schema
+// setup and subscribing the durable consumers as a side effect.
+func NewRouter(deps Deps) (*gin.Engine, error) {
+	dbpool := deps.DB
+
+	setupSchema(dbpool)
+	setupCommentsSchema(dbpool)
+
+	js, err := deps.NATS.JetStream()
+	if err != nil {
+		return nil, fmt.Errorf("getting JetStream context: %w", err)
+	}
+
+	if _, err := subscribeDurable(js, dbpool); err != nil {
+		return nil, fmt.Errorf("subscribing to post.updated: %w", err)
+	}
+
+	if _, err := subscribeDeletesDurable(js, dbpool); err != nil {
+		return nil, fmt.Errorf("subscribing to post.deleted: %w", err)
+	}
+
+	if _, err := subscribeCommentsDurable(js, dbpool); err != nil {
+		return nil, fmt.Errorf("subscribing to comment.created: %w", err)
+	}
+
+	if _, err := subscribeCommentDeletesDurable(js, dbpool); err != nil {
+		return nil, fmt.Errorf("subscribing to comment.deleted: %w", err)
+	}
+
+	authMiddleware := auth.New(deps.JWKSURL)
+
+	r := gin.Default()
+	r.POST("/admin/reindex", authMiddleware.RequireRole("admin"), reindexHandler(js, dbpool))
+	r.GET("/search", searchHandler(dbpool))
+
+	return r, nil
 }
 
 func main() {
@@ -38,8 +90,6 @@ func main() {
 	}
 	defer dbpool.Close()
 
-	setupSchema(dbpool)
-
 	// --- NATS connection ---
 	natsURL := os.Getenv("NATS_URL")
 	if natsURL == "" {
@@ -52,44 +102,15 @@ func main() {
 	}
 	defer nc.Close()
 
-	nc.Subscribe("post.updated", func(msg *nats.Msg) {
-		var post PostEvent
-		if err := json.Unmarshal(msg.Data, &post); err != nil {
-			log.Printf("Invalid message format: %v", err)
-			return
-		}
-		upsertPost(dbpool, post)
-	})
+	jwksURL := os.Getenv("AUTH_SERVICE_JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://localhost:8080/.jwk"
+	}
 
-	// --- Gin setup ---
-	r := gin.Default()
-	r.GET("/search", func(c *gin.Context) {
-		query := c.Query("q")
-
-		if query == "" {
-			c.JSON(400, gin.H{"error": "Query parameter 'q' is required"})
-			return
-		}
-
-		rows, err := dbpool.Query(context.Background(),
-			`SELECT post_id FROM posts_search_index WHERE content_tsv @@ to_tsquery('english', $1)`, query)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "search failed"})
-			return
-		}
-		defer rows.Close()
-
-		var postIDs []string
-		for rows.Next() {
-			var id string
-			if err := rows.Scan(&id); err != nil {
-				c.JSON(500, gin.H{"error": "failed to scan result"})
-				continue
-			}
-			postIDs = append(postIDs, id)
-		}
-		c.JSON(200, gin.H{"post_ids": postIDs})
-	})
+	r, err := NewRouter(Deps{DB: dbpool, NATS: nc, JWKSURL: jwksURL})
+	if err != nil {
+		log.Fatalf("Error building router: %v\n", err)
+	}
 
 	r.Run(":8083")
 }
@@ -99,8 +120,10 @@ func setupSchema(dbpool *pgxpool.Pool) {
 	_, err := dbpool.Exec(context.Background(), `
 		CREATE TABLE IF NOT EXISTS posts_search_index (
 			post_id UUID PRIMARY KEY,
+			user_id UUID,
 			content TEXT,
-			content_tsv TSVECTOR
+			content_tsv TSVECTOR,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 		);
 	`)
 	if err != nil {
@@ -139,11 +162,11 @@ func setupSchema(dbpool *pgxpool.Pool) {
 func upsertPost(dbpool *pgxpool.Pool, post PostEvent) {
 	// The trigger handles the 'content_tsv' column automatically!
 	_, err := dbpool.Exec(context.Background(), `
-		INSERT INTO posts_search_index (post_id, content)
-		VALUES ($1, $2)
+		INSERT INTO posts_search_index (post_id, user_id, content, created_at)
+		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (post_id)
-		DO UPDATE SET content = EXCLUDED.content;
-	`, post.ID, post.Content)
+		DO UPDATE SET content = EXCLUDED.content, user_id = EXCLUDED.user_id, created_at = EXCLUDED.created_at;
+	`, post.ID, post.UserID, post.Content, post.CreatedAt)
 
 	if err != nil {
 		log.Printf("Failed to upsert post %s: %v", post.ID, err)
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type searchResult struct {
+	PostID  string  `json:"post_id"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// searchHandler runs a ranked, paginated, filterable full-text search over
+// posts and comments. Unlike the original `to_tsquery`-based endpoint, it
+// accepts a natural query string (via websearch_to_tsquery), so "foo bar"
+// works instead of requiring callers to pre-format a tsquery.
+func searchHandler(dbpool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Query("q")
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
+			return
+		}
+
+		limit, offset, err := parseLimitOffset(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var author *uuid.UUID
+		if a := c.Query("author"); a != "" {
+			parsed, err := uuid.Parse(a)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "author must be a UUID"})
+				return
+			}
+			author = &parsed
+		}
+
+		var since *time.Time
+		if s := c.Query("since"); s != "" {
+			parsed, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC3339 timestamp"})
+				return
+			}
+			since = &parsed
+		}
+
+		highlight := c.DefaultQuery("highlight", "true") != "false"
+
+		results, total, err := runSearch(dbpool, query, limit, offset, author, since, highlight)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"results": results,
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
+
+func parseLimitOffset(c *gin.Context) (int, int, error) {
+	limit := defaultSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, 0, errInvalidLimit
+		}
+		if n > maxSearchLimit {
+			n = maxSearchLimit
+		}
+		limit = n
+	}
+
+	offset := 0
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errInvalidOffset
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
+
+var (
+	errInvalidLimit  = invalidQueryParamError("limit must be a positive integer")
+	errInvalidOffset = invalidQueryParamError("offset must be a non-negative integer")
+)
+
+type invalidQueryParamError string
+
+func (e invalidQueryParamError) Error() string { return string(e) }
+
+// runSearch ranks matches from both posts_search_index and
+// comments_search_index with ts_rank_cd, merges them, and paginates the
+// combined result. The author/since filters only apply to posts, since
+// comments_search_index doesn't carry that metadata — a comment hit simply
+// never matches when either filter is set.
+func runSearch(dbpool *pgxpool.Pool, query string, limit, offset int, author *uuid.UUID, since *time.Time, highlight bool) ([]searchResult, int, error) {
+	snippetExpr := "NULL::text"
+	if highlight {
+		snippetExpr = "ts_headline('english', content, websearch_to_tsquery('english', $1))"
+	}
+
+	sql := `
+		WITH matches AS (
+			SELECT post_id, content,
+				ts_rank_cd(content_tsv, websearch_to_tsquery('english', $1)) AS rank
+			FROM posts_search_index
+			WHERE content_tsv @@ websearch_to_tsquery('english', $1)
+				AND ($2::uuid IS NULL OR user_id = $2)
+				AND ($3::timestamptz IS NULL OR created_at >= $3)
+			UNION ALL
+			SELECT post_id, content,
+				ts_rank_cd(content_tsv, websearch_to_tsquery('english', $1)) AS rank
+			FROM comments_search_index
+			WHERE content_tsv @@ websearch_to_tsquery('english', $1)
+				AND $2::uuid IS NULL
+				AND $3::timestamptz IS NULL
+		)
+		SELECT post_id, ` + snippetExpr + `, rank, count(*) OVER() AS total
+		FROM matches
+		ORDER BY rank DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	rows, err := dbpool.Query(context.Background(), sql, query, author, since, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []searchResult
+	total := 0
+	for rows.Next() {
+		var r searchResult
+		var snippet *string
+		if err := rows.Scan(&r.PostID, &snippet, &r.Rank, &total); err != nil {
+			return nil, 0, err
+		}
+		if snippet != nil {
+			r.Snippet = *snippet
+		}
+		results = append(results, r)
+	}
+
+	return results, total, rows.Err()
+}
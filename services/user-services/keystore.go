@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// defaultRotationInterval is how often the keystore mints a new signing key
+// when KEY_ROTATION_INTERVAL is not set.
+const defaultRotationInterval = 24 * time.Hour
+
+// defaultRetainedKeys is how many past keys (including the current one) stay
+// valid for JWT verification after a rotation, when KEY_RETENTION_COUNT is
+// not set.
+const defaultRetainedKeys = 3
+
+// keyStore persists RSA signing keys in Postgres and keeps a rolling window
+// of them valid for verification so tokens signed under an older `kid`
+// continue to validate across rotations.
+type keyStore struct {
+	mu sync.RWMutex
+
+	pool     *pgxpool.Pool
+	retain   int
+	interval time.Duration
+
+	currentKID string
+	privateKey *rsa.PrivateKey
+	keySet     jwk.Set
+	kidOrder   []string // oldest first; which key rotate() evicts when over retain
+}
+
+func newKeyStore(pool *pgxpool.Pool) (*keyStore, error) {
+	ks := &keyStore{
+		pool:     pool,
+		retain:   defaultRetainedKeys,
+		interval: defaultRotationInterval,
+		keySet:   jwk.NewSet(),
+	}
+
+	if v := os.Getenv("KEY_RETENTION_COUNT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid KEY_RETENTION_COUNT: %q", v)
+		}
+		ks.retain = n
+	}
+
+	if v := os.Getenv("KEY_ROTATION_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KEY_ROTATION_INTERVAL: %q", v)
+		}
+		ks.interval = d
+	}
+
+	if err := ks.ensureSchema(); err != nil {
+		return nil, err
+	}
+
+	if err := ks.load(); err != nil {
+		return nil, err
+	}
+
+	if ks.currentKID == "" {
+		if _, err := ks.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ks, nil
+}
+
+func (ks *keyStore) ensureSchema() error {
+	_, err := ks.pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS signing_keys (
+			kid TEXT PRIMARY KEY,
+			private_key_pem TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`)
+	return err
+}
+
+// load reads the most recent keys from Postgres, keeping up to ks.retain of
+// them in memory: the newest becomes the current signing key, and all of
+// them populate the public JWKS so older tokens still verify. kidOrder is
+// rebuilt oldest-first (the reverse of the DESC query) so rotate() can tell
+// which key to evict without guessing at jwk.Set's internal ordering.
+func (ks *keyStore) load() error {
+	rows, err := ks.pool.Query(context.Background(),
+		"SELECT kid, private_key_pem FROM signing_keys ORDER BY created_at DESC LIMIT $1",
+		ks.retain)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	keySet := jwk.NewSet()
+	var newestFirst []string
+	first := true
+	for rows.Next() {
+		var kid, pemStr string
+		if err := rows.Scan(&kid, &pemStr); err != nil {
+			return err
+		}
+
+		key, err := decodeRSAPrivateKeyPEM(pemStr)
+		if err != nil {
+			return fmt.Errorf("decoding signing key %s: %w", kid, err)
+		}
+
+		pubJWK, err := jwk.New(key.PublicKey)
+		if err != nil {
+			return err
+		}
+		pubJWK.Set(jwk.KeyIDKey, kid)
+		pubJWK.Set("alg", "RS256")
+		keySet.Add(pubJWK)
+		newestFirst = append(newestFirst, kid)
+
+		if first {
+			ks.currentKID = kid
+			ks.privateKey = key
+			first = false
+		}
+	}
+
+	ks.keySet = keySet
+	ks.kidOrder = reverseStrings(newestFirst)
+	return nil
+}
+
+// reverseStrings returns a new slice with ss in reverse order.
+func reverseStrings(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[len(ss)-1-i] = s
+	}
+	return out
+}
+
+// rotate generates a new RSA key, persists it, makes it the signing key, and
+// prunes any keys beyond the retention window.
+func (ks *keyStore) rotate() (string, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", fmt.Errorf("generating RSA key: %w", err)
+	}
+
+	pubJWK, err := jwk.New(privateKey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	jwk.AssignKeyID(pubJWK)
+	pubJWK.Set("alg", "RS256")
+	kid := pubJWK.KeyID()
+
+	pemStr := encodeRSAPrivateKeyPEM(privateKey)
+	if _, err := ks.pool.Exec(context.Background(),
+		"INSERT INTO signing_keys (kid, private_key_pem) VALUES ($1, $2)",
+		kid, pemStr,
+	); err != nil {
+		return "", fmt.Errorf("persisting signing key: %w", err)
+	}
+
+	if _, err := ks.pool.Exec(context.Background(), `
+		DELETE FROM signing_keys WHERE kid NOT IN (
+			SELECT kid FROM signing_keys ORDER BY created_at DESC LIMIT $1
+		)
+	`, ks.retain); err != nil {
+		log.Printf("Failed to prune old signing keys: %v", err)
+	}
+
+	ks.mu.Lock()
+	ks.currentKID = kid
+	ks.privateKey = privateKey
+	ks.keySet.Add(pubJWK)
+	ks.kidOrder = append(ks.kidOrder, kid)
+	for len(ks.kidOrder) > ks.retain {
+		oldest := ks.kidOrder[0]
+		ks.kidOrder = ks.kidOrder[1:]
+		if oldKey, ok := ks.keySet.LookupKeyID(oldest); ok {
+			ks.keySet.Remove(oldKey)
+		}
+	}
+	ks.mu.Unlock()
+
+	log.Printf("Rotated signing key, new kid=%s", kid)
+	return kid, nil
+}
+
+// signingKey returns the current key and its kid for signing new tokens.
+func (ks *keyStore) signingKey() (*rsa.PrivateKey, string) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.privateKey, ks.currentKID
+}
+
+// publicKeySet returns a snapshot of the JWKS of all currently-valid public
+// keys. It copies the keys into a fresh set under the lock rather than
+// returning ks.keySet itself, since callers (the /.jwk handler) marshal the
+// result outside any lock and a concurrent rotate() mutates ks.keySet's
+// backing slice.
+func (ks *keyStore) publicKeySet() jwk.Set {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	snapshot := jwk.NewSet()
+	for i := 0; i < ks.keySet.Len(); i++ {
+		if key, ok := ks.keySet.Get(i); ok {
+			snapshot.Add(key)
+		}
+	}
+	return snapshot
+}
+
+// startRotationLoop rotates the signing key on ks.interval until ctx is done.
+func (ks *keyStore) startRotationLoop(ctx context.Context) {
+	ticker := time.NewTicker(ks.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := ks.rotate(); err != nil {
+					log.Printf("Scheduled key rotation failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func encodeRSAPrivateKeyPEM(key *rsa.PrivateKey) string {
+	block := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func decodeRSAPrivateKeyPEM(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
@@ -2,28 +2,30 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/hariomop12/nginx/pkg/auth"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
-	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/nats-io/nats.go"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var (
-	dbPool    *pgxpool.Pool
-	rsaKey    *rsa.PrivateKey
-	rsaKeyJWK jwk.Key
-	jwkKeySet jwk.Set
-)
+// server holds everything the auth service's handlers need, so NewRouter
+// can build one per call instead of stashing dependencies in package
+// globals — two NewRouter calls in the same process get fully independent
+// state.
+type server struct {
+	db         *pgxpool.Pool
+	nc         *nats.Conn // optional: revocation events aren't published if nil
+	keys       *keyStore
+	revocation *auth.RevocationCache
+}
 
 type User struct {
 	ID       uuid.UUID `json:"id"`
@@ -41,48 +43,104 @@ type loginRequest struct {
 	Password string `json:"password" binding:"required,min=8"`
 }
 
+// Deps holds everything NewRouter needs to wire up the service, kept
+// separate from the env-var lookups in main. The integration tests under
+// test/ don't build the router through Deps: they start the service with
+// `go run` and configure it through the same environment variables.
+type Deps struct {
+	DB      *pgxpool.Pool
+	NATS    *nats.Conn // optional: revocation events aren't published if nil
+	JWKSURL string     // this service's own /.jwk, used to gate /admin routes
+}
+
+// NewRouter builds the auth service's routes against deps, running schema
+// migrations and starting the keystore's rotation loop as a side effect.
+// Each call builds its own server, so independent NewRouter calls (e.g.
+// one per test) never share state.
+func NewRouter(deps Deps) (*gin.Engine, error) {
+	if err := ensureOAuthColumns(deps.DB); err != nil {
+		return nil, fmt.Errorf("migrating users table for SSO: %w", err)
+	}
+	if err := ensureRefreshTokenSchema(deps.DB); err != nil {
+		return nil, fmt.Errorf("creating refresh_tokens table: %w", err)
+	}
+	if err := ensureRolesSchema(deps.DB); err != nil {
+		return nil, fmt.Errorf("creating roles tables: %w", err)
+	}
+
+	keys, err := newKeyStore(deps.DB)
+	if err != nil {
+		return nil, fmt.Errorf("initializing keystore: %w", err)
+	}
+	keys.startRotationLoop(context.Background())
+
+	_, currentKID := keys.signingKey()
+	log.Println("Keystore ready, signing kid:", currentKID)
+
+	s := &server{
+		db:         deps.DB,
+		nc:         deps.NATS,
+		keys:       keys,
+		revocation: auth.NewRevocationCache(),
+	}
+
+	authMiddleware := auth.New(deps.JWKSURL)
+	authMiddleware.UseRevocationCache(s.revocation)
+
+	r := gin.Default()
+	r.POST("/register", s.registerHandler)
+	r.POST("/login", s.loginHandler)
+	r.POST("/refresh", s.refreshHandler)
+	r.POST("/logout", s.logoutHandler)
+	r.GET("/.jwk", s.jwkHandler)
+	r.POST("/.jwk/rotate", authMiddleware.RequireRole("admin"), s.rotateKeyHandler)
+	r.GET("/revoked/:jti", s.revocationCheckHandler)
+	r.GET("/oauth/:provider/login", oauthLoginHandler)
+	r.GET("/oauth/:provider/callback", s.oauthCallbackHandler)
+	r.POST("/admin/users/:id/roles", authMiddleware.RequireRole("admin"), s.assignRoleHandler)
+	r.GET("/health", s.healthCheckHandler)
+
+	return r, nil
+}
+
 func main() {
 	// Load environment variables from .env file for local development
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	var err error
-
 	databaseUrl := os.Getenv("DATABASE_URL")
-	dbPool, err = pgxpool.Connect(context.Background(), databaseUrl)
+	dbPool, err := pgxpool.Connect(context.Background(), databaseUrl)
 	if err != nil {
 		log.Fatalf("Unable to connect to database: %v\n", err)
 	}
 	defer dbPool.Close()
 
-	rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		log.Fatalf("Error generating RSA key: %v\n", err)
+	var natsConn *nats.Conn
+	if natsURL := os.Getenv("NATS_URL"); natsURL != "" {
+		natsConn, err = nats.Connect(natsURL)
+		if err != nil {
+			log.Fatalf("Unable to connect to NATS: %v\n", err)
+		}
+		defer natsConn.Close()
+	} else {
+		log.Println("NATS_URL not set, revocation events will not be published")
 	}
 
-	rsaKeyJWK, err = jwk.New(rsaKey.PublicKey)
-	if err != nil {
-		log.Fatalf("Error creating JWK from RSA key: %v\n", err)
+	jwksURL := os.Getenv("AUTH_SERVICE_JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://localhost:8080/.jwk"
 	}
-	jwk.AssignKeyID(rsaKeyJWK)
-	rsaKeyJWK.Set("alg", "RS256")
-
-	jwkKeySet = jwk.NewSet()
-	jwkKeySet.Add(rsaKeyJWK)
 
-	log.Println("RSA key pair for JWT generated.")
-
-	r := gin.Default()
-	r.POST("/register", registerHandler)
-	r.POST("/login", loginHandler)
-	r.GET("/.jwk", jwkHandler)
-	r.GET("/health", healthCheckHandler)
+	r, err := NewRouter(Deps{DB: dbPool, NATS: natsConn, JWKSURL: jwksURL})
+	if err != nil {
+		log.Fatalf("Error building router: %v\n", err)
+	}
 
 	r.Run(":8080")
 }
 
-func registerHandler(c *gin.Context) {
+func (s *server) registerHandler(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -101,7 +159,7 @@ func registerHandler(c *gin.Context) {
 		Password: string(hashedPassword),
 	}
 
-	_, err = dbPool.Exec(context.Background(),
+	_, err = s.db.Exec(context.Background(),
 		"INSERT INTO users (id, username, password) VALUES ($1, $2, $3)",
 		newUser.ID, newUser.Username, newUser.Password)
 
@@ -110,10 +168,22 @@ func registerHandler(c *gin.Context) {
 		return
 	}
 
+	if err := s.assignRole(newUser.ID, defaultRole); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign default role"})
+		return
+	}
+
+	if isBootstrapAdmin(newUser.Username) {
+		if err := s.assignRole(newUser.ID, "admin"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign admin role"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusCreated, gin.H{"id": newUser.ID, "email": newUser.Username})
 }
 
-func loginHandler(c *gin.Context) {
+func (s *server) loginHandler(c *gin.Context) {
 	var req loginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -121,7 +191,7 @@ func loginHandler(c *gin.Context) {
 	}
 
 	var user User
-	err := dbPool.QueryRow(
+	err := s.db.QueryRow(
 		context.Background(),
 		"SELECT id, username, password FROM users WHERE username = $1",
 		req.Email,
@@ -137,29 +207,33 @@ func loginHandler(c *gin.Context) {
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
-		"sub":   user.ID,
-		"email": user.Username,
-		"roles": []string{"user"},
-		"exp":   time.Now().Add(time.Hour * 24).Unix(),
-	})
-	token.Header["kid"] = rsaKeyJWK.KeyID()
-
-	tokenString, err := token.SignedString(rsaKey)
+	accessToken, refreshToken, err := s.issueTokenPair(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	c.JSON(http.StatusOK, gin.H{"access_token": accessToken, "refresh_token": refreshToken})
 }
 
-func jwkHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, jwkKeySet)
+func (s *server) jwkHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.keys.publicKeySet())
+}
+
+// rotateKeyHandler mints a new signing key immediately, outside the regular
+// rotation schedule. Intended for operator-triggered rotation, e.g. after a
+// suspected key compromise.
+func (s *server) rotateKeyHandler(c *gin.Context) {
+	kid, err := s.keys.rotate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate signing key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"kid": kid})
 }
 
-func healthCheckHandler(c *gin.Context) {
-	if err := dbPool.Ping(context.Background()); err != nil {
+func (s *server) healthCheckHandler(c *gin.Context) {
+	if err := s.db.Ping(context.Background()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "unhealthy", "error": err.Error()})
 		return
 	}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ensureOAuthColumns adds the columns SSO login needs to the existing
+// users table and relaxes password to nullable, since SSO-only accounts
+// never set one.
+func ensureOAuthColumns(pool *pgxpool.Pool) error {
+	_, err := pool.Exec(context.Background(), `
+		ALTER TABLE users
+			ADD COLUMN IF NOT EXISTS oauth_provider TEXT,
+			ADD COLUMN IF NOT EXISTS oauth_subject TEXT,
+			ALTER COLUMN password DROP NOT NULL;
+	`)
+	return err
+}
+
+// oauthStateTTL bounds how long a login attempt has to complete the
+// provider round trip before its state is forgotten.
+const oauthStateTTL = 10 * time.Minute
+
+// oauthProvider holds the per-provider endpoints and credentials needed to
+// drive an authorization-code flow. Providers are configured entirely
+// through environment variables so new ones can be added without code
+// changes to the handlers themselves.
+type oauthProvider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	RedirectURL  string
+	Scope        string
+}
+
+var oauthProviders = loadOAuthProviders()
+
+func loadOAuthProviders() map[string]oauthProvider {
+	providers := map[string]oauthProvider{
+		"github": {
+			Name:        "github",
+			AuthURL:     "https://github.com/login/oauth/authorize",
+			TokenURL:    "https://github.com/login/oauth/access_token",
+			UserInfoURL: "https://api.github.com/user",
+			Scope:       "read:user user:email",
+		},
+		"google": {
+			Name:        "google",
+			AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL:    "https://oauth2.googleapis.com/token",
+			UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+			Scope:       "openid email profile",
+		},
+	}
+
+	for key, p := range providers {
+		env := strings.ToUpper(key)
+		p.ClientID = os.Getenv(env + "_CLIENT_ID")
+		p.ClientSecret = os.Getenv(env + "_CLIENT_SECRET")
+		p.RedirectURL = os.Getenv(env + "_REDIRECT_URL")
+		providers[key] = p
+	}
+
+	if authURL := os.Getenv("OIDC_AUTH_URL"); authURL != "" {
+		providers["oidc"] = oauthProvider{
+			Name:         "oidc",
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			AuthURL:      authURL,
+			TokenURL:     os.Getenv("OIDC_TOKEN_URL"),
+			UserInfoURL:  os.Getenv("OIDC_USERINFO_URL"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scope:        "openid email profile",
+		}
+	}
+
+	return providers
+}
+
+// oauthStateCache is a short-lived, in-memory store for the random `state`
+// values minted during /oauth/:provider/login, keyed by the state itself.
+// A single-instance in-memory map is sufficient here because the state
+// cookie and the cache entry are always written by the same process that
+// later validates the callback; swap for Redis if the service is scaled
+// out behind a load balancer without sticky sessions.
+type oauthStateCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+var oauthStates = &oauthStateCache{entries: make(map[string]time.Time)}
+
+func (c *oauthStateCache) put(state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[state] = time.Now().Add(oauthStateTTL)
+}
+
+// consume validates and removes a state value. It returns false if the
+// state is unknown or expired.
+func (c *oauthStateCache) consume(state string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt, ok := c.entries[state]
+	delete(c.entries, state)
+	if !ok || time.Now().After(expiresAt) {
+		return false
+	}
+	return true
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// oauthLoginHandler starts an authorization-code flow: it mints a random
+// state, remembers it in oauthStates, stores it in a cookie so the
+// callback can confirm this browser made the request, and redirects to the
+// provider's consent screen.
+func oauthLoginHandler(c *gin.Context) {
+	provider, ok := oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown OAuth provider"})
+		return
+	}
+
+	state, err := randomState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start OAuth flow"})
+		return
+	}
+	oauthStates.put(state)
+
+	c.SetCookie("oauth_state", state, int(oauthStateTTL.Seconds()), "/", "", false, true)
+
+	authURL, _ := url.Parse(provider.AuthURL)
+	q := authURL.Query()
+	q.Set("client_id", provider.ClientID)
+	q.Set("redirect_uri", provider.RedirectURL)
+	q.Set("response_type", "code")
+	q.Set("scope", provider.Scope)
+	q.Set("state", state)
+	authURL.RawQuery = q.Encode()
+
+	c.Redirect(http.StatusFound, authURL.String())
+}
+
+// oauthTokenResponse covers the fields we need from a provider's token
+// endpoint; unknown fields are ignored by encoding/json.
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// oauthUserInfo is the subset of a provider's userinfo/user response we
+// need to identify the account. GitHub uses "id" (numeric) and "email";
+// Google/OIDC use "sub" and "email".
+type oauthUserInfo struct {
+	ID    interface{} `json:"id"`
+	Sub   string      `json:"sub"`
+	Email string      `json:"email"`
+}
+
+func (u oauthUserInfo) subject() string {
+	if u.Sub != "" {
+		return u.Sub
+	}
+	if u.ID == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", u.ID)
+}
+
+// oauthCallbackHandler completes the flow: it verifies the state cookie
+// against the cached value, exchanges the authorization code for an access
+// token, fetches the provider's user info, links or creates a User row
+// keyed by provider+subject, and issues the same RS256 JWT password logins
+// get.
+func (s *server) oauthCallbackHandler(c *gin.Context) {
+	provider, ok := oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown OAuth provider"})
+		return
+	}
+
+	state := c.Query("state")
+	cookieState, err := c.Cookie("oauth_state")
+	if err != nil || state == "" || state != cookieState || !oauthStates.consume(state) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired OAuth state"})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization code"})
+		return
+	}
+
+	token, err := exchangeCodeForToken(c.Request.Context(), provider, code)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to exchange authorization code"})
+		return
+	}
+
+	info, err := fetchOAuthUserInfo(c.Request.Context(), provider, token)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch user info"})
+		return
+	}
+
+	user, err := s.findOrCreateOAuthUser(provider.Name, info.subject(), info.Email)
+	if errors.Is(err, errOAuthEmailRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This provider did not share an email address; cannot complete sign-in"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve user"})
+		return
+	}
+
+	accessToken, refreshToken, err := s.issueTokenPair(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"access_token": accessToken, "refresh_token": refreshToken})
+}
+
+func exchangeCodeForToken(ctx context.Context, provider oauthProvider, code string) (string, error) {
+	form := url.Values{}
+	form.Set("client_id", provider.ClientID)
+	form.Set("client_secret", provider.ClientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectURL)
+	form.Set("grant_type", "authorization_code")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+
+	var tr oauthTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if tr.AccessToken == "" {
+		return "", errors.New("token endpoint did not return an access_token")
+	}
+	return tr.AccessToken, nil
+}
+
+func fetchOAuthUserInfo(ctx context.Context, provider oauthProvider, accessToken string) (oauthUserInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, provider.UserInfoURL, nil)
+	if err != nil {
+		return oauthUserInfo{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return oauthUserInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return oauthUserInfo{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return oauthUserInfo{}, fmt.Errorf("userinfo endpoint returned %d", resp.StatusCode)
+	}
+
+	var info oauthUserInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return oauthUserInfo{}, err
+	}
+	if info.subject() == "" {
+		return oauthUserInfo{}, errors.New("userinfo response missing subject")
+	}
+	return info, nil
+}
+
+// errOAuthEmailRequired is returned when a provider's userinfo response
+// carries no email. Without one, findOrCreateOAuthUser has nothing to link
+// against or display, and a blank Username would collide with or shadow
+// other email-less signups, so we reject outright rather than create one.
+var errOAuthEmailRequired = errors.New("oauth provider did not return an email address")
+
+// findOrCreateOAuthUser looks up a user by provider+subject, falling back
+// to linking an existing password account with the same email so users who
+// registered with a password can adopt an SSO identity without creating a
+// second account.
+func (s *server) findOrCreateOAuthUser(provider, subject, email string) (User, error) {
+	if email == "" {
+		return User{}, errOAuthEmailRequired
+	}
+
+	var user User
+	err := s.db.QueryRow(context.Background(),
+		"SELECT id, username FROM users WHERE oauth_provider = $1 AND oauth_subject = $2",
+		provider, subject,
+	).Scan(&user.ID, &user.Username)
+	if err == nil {
+		return user, nil
+	}
+
+	err = s.db.QueryRow(context.Background(),
+		"UPDATE users SET oauth_provider = $1, oauth_subject = $2 WHERE username = $3 RETURNING id, username",
+		provider, subject, email,
+	).Scan(&user.ID, &user.Username)
+	if err == nil {
+		return user, nil
+	}
+
+	user = User{ID: uuid.New(), Username: email}
+	_, err = s.db.Exec(context.Background(),
+		"INSERT INTO users (id, username, oauth_provider, oauth_subject) VALUES ($1, $2, $3, $4)",
+		user.ID, user.Username, provider, subject)
+	if err != nil {
+		return User{}, fmt.Errorf("creating SSO user: %w", err)
+	}
+	return user, nil
+}
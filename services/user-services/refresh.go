@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/hariomop12/nginx/pkg/auth"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// refreshTokenTTL is how long an issued refresh token remains valid.
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	AccessToken  string `json:"access_token"`
+}
+
+// revocationEvent mirrors the payload auth.RevocationCache.Subscribe expects
+// on auth.RevokedSubject.
+type revocationEvent struct {
+	JTI string `json:"jti"`
+	Exp int64  `json:"exp"`
+}
+
+func ensureRefreshTokenSchema(pool *pgxpool.Pool) error {
+	_, err := pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id UUID PRIMARY KEY,
+			user_id UUID NOT NULL,
+			hashed_token TEXT NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			revoked_at TIMESTAMPTZ,
+			replaced_by UUID
+		);
+	`)
+	return err
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// issueTokenPair mints a new access/refresh pair for the given user,
+// persisting the refresh token's hash so it can be looked up and revoked
+// without ever storing it in plaintext.
+func (s *server) issueTokenPair(user User) (accessToken string, refreshToken string, err error) {
+	signingKey, kid := s.keys.signingKey()
+
+	roles, err := s.userRoles(user.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	jti := uuid.New().String()
+	claims := jwt.MapClaims{
+		"sub":   user.ID,
+		"email": user.Username,
+		"roles": roles,
+		"jti":   jti,
+		"exp":   time.Now().Add(time.Hour * 24).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	accessToken, err = token.SignedString(signingKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err = newOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = s.db.Exec(context.Background(),
+		"INSERT INTO refresh_tokens (id, user_id, hashed_token, expires_at) VALUES ($1, $2, $3, $4)",
+		uuid.New(), user.ID, hashRefreshToken(refreshToken), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// refreshHandler rotates a refresh token: the presented token is marked
+// replaced and a fresh access/refresh pair is issued. Presenting an
+// already-rotated or revoked token fails closed.
+func (s *server) refreshHandler(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var id, userID uuid.UUID
+	var expiresAt time.Time
+	var revokedAt *time.Time
+	err := s.db.QueryRow(context.Background(),
+		"SELECT id, user_id, expires_at, revoked_at FROM refresh_tokens WHERE hashed_token = $1",
+		hashRefreshToken(req.RefreshToken),
+	).Scan(&id, &userID, &expiresAt, &revokedAt)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if revokedAt != nil || time.Now().After(expiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token is no longer valid"})
+		return
+	}
+
+	var user User
+	err = s.db.QueryRow(context.Background(),
+		"SELECT id, username FROM users WHERE id = $1", userID,
+	).Scan(&user.ID, &user.Username)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	accessToken, refreshToken, err := s.issueTokenPair(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue tokens"})
+		return
+	}
+
+	var newID uuid.UUID
+	if err := s.db.QueryRow(context.Background(),
+		"SELECT id FROM refresh_tokens WHERE hashed_token = $1",
+		hashRefreshToken(refreshToken),
+	).Scan(&newID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate refresh token"})
+		return
+	}
+	_, err = s.db.Exec(context.Background(),
+		"UPDATE refresh_tokens SET revoked_at = now(), replaced_by = $1 WHERE id = $2",
+		newID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"access_token": accessToken, "refresh_token": refreshToken})
+}
+
+// logoutHandler revokes the refresh token so it can no longer mint new
+// access tokens, and — if the caller includes the access token it was
+// issued alongside — publishes its jti on auth.revoked so other services
+// reject it for the remainder of its natural lifetime.
+func (s *server) logoutHandler(c *gin.Context) {
+	var req logoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := s.db.Exec(context.Background(),
+		"UPDATE refresh_tokens SET revoked_at = now() WHERE hashed_token = $1 AND revoked_at IS NULL",
+		hashRefreshToken(req.RefreshToken))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke refresh token"})
+		return
+	}
+
+	if req.AccessToken != "" {
+		if jti, exp, err := s.parseOwnAccessToken(req.AccessToken); err == nil {
+			s.revocation.Revoke(jti, exp)
+			s.publishRevocation(jti, exp)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
+}
+
+// parseOwnAccessToken verifies an access token against this service's own
+// JWKS and returns its jti and expiry, so logout can revoke it even though
+// the caller only has the opaque refresh token to authenticate with.
+func (s *server) parseOwnAccessToken(tokenString string) (string, int64, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := s.keys.publicKeySet().LookupKeyID(kid)
+		if !ok {
+			return nil, errors.New("unknown kid")
+		}
+		var raw interface{}
+		if err := key.Raw(&raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	})
+	if err != nil || !token.Valid {
+		return "", 0, errors.New("invalid access token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", 0, errors.New("invalid claims")
+	}
+	jti, _ := claims["jti"].(string)
+	expFloat, _ := claims["exp"].(float64)
+	if jti == "" {
+		return "", 0, errors.New("token has no jti")
+	}
+	return jti, int64(expFloat), nil
+}
+
+func (s *server) publishRevocation(jti string, exp int64) {
+	if s.nc == nil {
+		return
+	}
+	payload, err := json.Marshal(revocationEvent{JTI: jti, Exp: exp})
+	if err != nil {
+		return
+	}
+	if err := s.nc.Publish(auth.RevokedSubject, payload); err != nil {
+		gin.DefaultErrorWriter.Write([]byte("failed to publish revocation event: " + err.Error() + "\n"))
+	}
+}
+
+// revocationCheckHandler lets services that don't subscribe to NATS ask
+// directly whether a jti has been revoked.
+func (s *server) revocationCheckHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"revoked": s.revocation.IsRevoked(c.Param("jti"))})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// defaultRole is granted to every newly registered user.
+const defaultRole = "user"
+
+// bootstrapAdminEmailEnv names the environment variable whose value, if
+// set, is granted the "admin" role the moment that email registers. This is
+// the only path to the first admin: /admin/users/:id/roles is itself gated
+// behind RequireRole("admin"), so without it /admin/* would be permanently
+// unreachable.
+const bootstrapAdminEmailEnv = "BOOTSTRAP_ADMIN_EMAIL"
+
+// isBootstrapAdmin reports whether email matches BOOTSTRAP_ADMIN_EMAIL.
+func isBootstrapAdmin(email string) bool {
+	bootstrap := os.Getenv(bootstrapAdminEmailEnv)
+	return bootstrap != "" && bootstrap == email
+}
+
+type assignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func ensureRolesSchema(pool *pgxpool.Pool) error {
+	_, err := pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS roles (
+			id UUID PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE
+		);
+
+		CREATE TABLE IF NOT EXISTS user_roles (
+			user_id UUID NOT NULL,
+			role_id UUID NOT NULL REFERENCES roles(id),
+			PRIMARY KEY (user_id, role_id)
+		);
+
+		INSERT INTO roles (id, name)
+		VALUES (gen_random_uuid(), 'user'), (gen_random_uuid(), 'admin')
+		ON CONFLICT (name) DO NOTHING;
+	`)
+	return err
+}
+
+// errUnknownRole is returned by assignRole when roleName doesn't match any
+// row in the roles table.
+var errUnknownRole = errors.New("unknown role")
+
+// assignRole grants roleName to userID, creating the join row if it doesn't
+// already exist. It returns errUnknownRole if roleName isn't a row in the
+// roles table, since the INSERT ... SELECT below would otherwise silently
+// affect zero rows.
+func (s *server) assignRole(userID uuid.UUID, roleName string) error {
+	tag, err := s.db.Exec(context.Background(), `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = $2
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`, userID, roleName)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		if err := s.db.QueryRow(context.Background(),
+			"SELECT EXISTS(SELECT 1 FROM roles WHERE name = $1)", roleName,
+		).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return errUnknownRole
+		}
+	}
+	return nil
+}
+
+// userRoles returns the roles granted to userID, falling back to
+// defaultRole so accounts created before roles existed keep working.
+func (s *server) userRoles(userID uuid.UUID) ([]string, error) {
+	rows, err := s.db.Query(context.Background(), `
+		SELECT r.name FROM roles r
+		JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		roles = append(roles, name)
+	}
+	if len(roles) == 0 {
+		return []string{defaultRole}, nil
+	}
+	return roles, nil
+}
+
+// assignRoleHandler lets an admin grant a role to another user. Mounted
+// behind RequireRole("admin").
+func (s *server) assignRoleHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	var req assignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.assignRole(userID, req.Role); err != nil {
+		if errors.Is(err, errUnknownRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": req.Role})
+}
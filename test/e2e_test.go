@@ -0,0 +1,228 @@
+package e2e
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// randomEmail returns a unique address so repeated test runs against the
+// same container never collide on the users table's unique constraint.
+func randomEmail(t *testing.T) string {
+	t.Helper()
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("generating random email: %v", err)
+	}
+	return fmt.Sprintf("e2e-%s@example.com", hex.EncodeToString(b))
+}
+
+const testPassword = "correct-horse-battery"
+
+func postJSON(t *testing.T, url string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshaling request body: %v", err)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decoding response from %s: %v", url, err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func register(t *testing.T, authURL, email string) {
+	t.Helper()
+	status, body := postJSON(t, authURL+"/register", map[string]string{
+		"email":    email,
+		"password": testPassword,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("register: expected 201, got %d (%v)", status, body)
+	}
+}
+
+func login(t *testing.T, authURL, email string) (accessToken string) {
+	t.Helper()
+	status, body := postJSON(t, authURL+"/login", map[string]string{
+		"email":    email,
+		"password": testPassword,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("login: expected 200, got %d (%v)", status, body)
+	}
+	accessToken, _ = body["access_token"].(string)
+	if accessToken == "" {
+		t.Fatalf("login: response had no access_token: %v", body)
+	}
+	return accessToken
+}
+
+// verifyAgainstJWKS checks accessToken's signature against authURL's
+// published JWKS, the same way pkg/auth's middleware does, so this test
+// fails if a post-register/login token isn't actually verifiable through
+// the public endpoint other services rely on.
+func verifyAgainstJWKS(t *testing.T, authURL, accessToken string) {
+	t.Helper()
+	resp, err := http.Get(authURL + "/.jwk")
+	if err != nil {
+		t.Fatalf("fetching JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		t.Fatalf("decoding JWKS: %v", err)
+	}
+	keySet, err := jwk.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing JWKS: %v", err)
+	}
+
+	token, err := jwt.Parse(accessToken, func(tok *jwt.Token) (interface{}, error) {
+		kid, _ := tok.Header["kid"].(string)
+		key, ok := keySet.LookupKeyID(kid)
+		if !ok {
+			return nil, fmt.Errorf("kid %q not found in JWKS", kid)
+		}
+		var pub interface{}
+		if err := key.Raw(&pub); err != nil {
+			return nil, err
+		}
+		return pub, nil
+	})
+	if err != nil || !token.Valid {
+		t.Fatalf("access token did not verify against JWKS: %v", err)
+	}
+}
+
+func TestRegisterLoginJWKSVerify(t *testing.T) {
+	inf := startInfra(t)
+	auth := NewAuthServer(t, inf, "")
+
+	email := randomEmail(t)
+	register(t, auth.baseURL, email)
+	accessToken := login(t, auth.baseURL, email)
+	verifyAgainstJWKS(t, auth.baseURL, accessToken)
+}
+
+func TestDuplicateEmailRegistrationRejected(t *testing.T) {
+	inf := startInfra(t)
+	auth := NewAuthServer(t, inf, "")
+
+	email := randomEmail(t)
+	register(t, auth.baseURL, email)
+
+	status, body := postJSON(t, auth.baseURL+"/register", map[string]string{
+		"email":    email,
+		"password": testPassword,
+	})
+	if status == http.StatusCreated {
+		t.Fatalf("registering the same email twice should fail, got 201: %v", body)
+	}
+}
+
+func TestInvalidJWTRejectedByPostsService(t *testing.T) {
+	inf := startInfra(t)
+	auth := NewAuthServer(t, inf, "")
+	posts := NewPostsServer(t, inf, auth.baseURL)
+
+	req, err := http.NewRequest(http.MethodPost, posts.baseURL+"/posts",
+		bytes.NewReader([]byte(`{"title":"hi","content":"hi"}`)))
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer not-a-real-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /posts: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an invalid bearer token, got %d", resp.StatusCode)
+	}
+}
+
+// TestCreatePostPropagatesToSearch drives the full create-post path: the
+// post lands in post-services' outbox, the outbox worker publishes it to
+// JetStream, search-service's durable consumer indexes it, and it becomes
+// findable via GET /search — with no direct call between post-services and
+// search-service.
+func TestCreatePostPropagatesToSearch(t *testing.T) {
+	inf := startInfra(t)
+	auth := NewAuthServer(t, inf, "")
+	posts := NewPostsServer(t, inf, auth.baseURL)
+	search := NewSearchServer(t, inf, auth.baseURL)
+
+	email := randomEmail(t)
+	register(t, auth.baseURL, email)
+	accessToken := login(t, auth.baseURL, email)
+
+	content := fmt.Sprintf("hunting for the unique token %s in search", hex.EncodeToString([]byte(email)))
+	req, err := http.NewRequest(http.MethodPost, posts.baseURL+"/posts",
+		bytes.NewReader(mustJSON(t, map[string]string{
+			"title":   "e2e post",
+			"content": content,
+		})))
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /posts: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 creating post, got %d", resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		searchResp, err := http.Get(fmt.Sprintf("%s/search?q=%s", search.baseURL, "unique"))
+		if err == nil {
+			var result struct {
+				Results []map[string]interface{} `json:"results"`
+			}
+			if json.NewDecoder(searchResp.Body).Decode(&result) == nil && len(result.Results) > 0 {
+				searchResp.Body.Close()
+				return
+			}
+			searchResp.Body.Close()
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("post never showed up in search within the deadline")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshaling JSON: %v", err)
+	}
+	return raw
+}
@@ -0,0 +1,174 @@
+// Package e2e exercises the services as deployed, not in-process: each
+// service is started as its own `go run` process against real Postgres and
+// NATS containers, and tests drive it over HTTP exactly like a real client
+// would. That's what lets these tests catch the things unit tests can't —
+// cross-service JWKS verification, outbox-to-NATS-to-search propagation —
+// without relying on every service exposing an importable (non-main)
+// package.
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	tcnats "github.com/testcontainers/testcontainers-go/modules/nats"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// infra is the Postgres + NATS pair every service under test connects to.
+// Each service sticks to its own tables and subjects, so one shared pair
+// per test run is enough — standing up fresh containers per service would
+// only slow the suite down for no isolation benefit.
+type infra struct {
+	databaseURL string
+	natsURL     string
+}
+
+// startInfra brings up the shared Postgres and NATS containers and
+// registers their teardown on t's cleanup.
+func startInfra(t *testing.T) infra {
+	t.Helper()
+	ctx := context.Background()
+
+	pg, err := postgres.RunContainer(ctx,
+		testcontainers.WithImage("postgres:16-alpine"),
+		postgres.WithDatabase("nginx"),
+		postgres.WithUsername("nginx"),
+		postgres.WithPassword("nginx"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).WithStartupTimeout(60*time.Second)),
+	)
+	if err != nil {
+		t.Fatalf("starting postgres container: %v", err)
+	}
+	t.Cleanup(func() { _ = pg.Terminate(ctx) })
+
+	dbURL, err := pg.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("getting postgres connection string: %v", err)
+	}
+
+	ns, err := tcnats.RunContainer(ctx, testcontainers.WithImage("nats:2.10-alpine"))
+	if err != nil {
+		t.Fatalf("starting nats container: %v", err)
+	}
+	t.Cleanup(func() { _ = ns.Terminate(ctx) })
+
+	natsURL, err := ns.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("getting nats connection string: %v", err)
+	}
+
+	return infra{databaseURL: dbURL, natsURL: natsURL}
+}
+
+// runningService is a service package started via `go run`, reachable at
+// baseURL once startService has confirmed it's accepting connections.
+type runningService struct {
+	baseURL string
+}
+
+// repoRoot locates the module root from this file's own location, so `go
+// test ./test/...` works regardless of the caller's working directory.
+func repoRoot() string {
+	_, thisFile, _, _ := runtime.Caller(0)
+	return filepath.Dir(filepath.Dir(thisFile))
+}
+
+// testLogWriter adapts t.Log to io.Writer so a child service's stdout/stderr
+// shows up under `go test -v` instead of vanishing.
+type testLogWriter struct{ t *testing.T }
+
+func (w testLogWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(p))
+	return len(p), nil
+}
+
+// startService runs the package at dir (relative to the module root) via
+// `go run`, pointed at infra, and waits for it to start accepting
+// connections on port before returning. extraEnv is appended after
+// DATABASE_URL/NATS_URL so callers can set AUTH_SERVICE_JWKS_URL,
+// BOOTSTRAP_ADMIN_EMAIL, etc.
+func startService(t *testing.T, dir string, port int, inf infra, extraEnv ...string) runningService {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, "go", "run", "./"+dir)
+	cmd.Dir = repoRoot()
+	cmd.Env = append(cmd.Environ(),
+		"DATABASE_URL="+inf.databaseURL,
+		"NATS_URL="+inf.natsURL,
+	)
+	cmd.Env = append(cmd.Env, extraEnv...)
+	cmd.Stdout = testLogWriter{t}
+	cmd.Stderr = testLogWriter{t}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		t.Fatalf("starting %s: %v", dir, err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = cmd.Wait()
+	})
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitUntilUp(t, baseURL)
+	return runningService{baseURL: baseURL}
+}
+
+// waitUntilUp polls baseURL until it answers any HTTP response — `go run`'s
+// compile step means the first requests would otherwise race the build —
+// or fails the test once it's given the service 30s to come up.
+func waitUntilUp(t *testing.T, baseURL string) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(baseURL + "/")
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			return
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+	t.Fatalf("%s never came up", baseURL)
+}
+
+// NewAuthServer starts user-services (registration, login, JWKS, roles)
+// against inf. bootstrapAdminEmail, if non-empty, is granted the admin role
+// on registration, mirroring BOOTSTRAP_ADMIN_EMAIL in production.
+func NewAuthServer(t *testing.T, inf infra, bootstrapAdminEmail string) runningService {
+	t.Helper()
+	var env []string
+	if bootstrapAdminEmail != "" {
+		env = append(env, "BOOTSTRAP_ADMIN_EMAIL="+bootstrapAdminEmail)
+	}
+	return startService(t, "services/user-services", 8080, inf, env...)
+}
+
+// NewPostsServer starts post-services against inf, verifying bearer tokens
+// against authBaseURL's JWKS.
+func NewPostsServer(t *testing.T, inf infra, authBaseURL string) runningService {
+	t.Helper()
+	return startService(t, "services/post-services", 8082, inf,
+		"AUTH_SERVICE_JWKS_URL="+authBaseURL+"/.jwk")
+}
+
+// NewSearchServer starts search-service against inf, verifying bearer
+// tokens against authBaseURL's JWKS.
+func NewSearchServer(t *testing.T, inf infra, authBaseURL string) runningService {
+	t.Helper()
+	return startService(t, "services/search-service", 8083, inf,
+		"AUTH_SERVICE_JWKS_URL="+authBaseURL+"/.jwk")
+}